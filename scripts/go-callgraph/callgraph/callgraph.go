@@ -0,0 +1,448 @@
+// Package callgraph extracts static call relationships from Go packages.
+//
+// It loads every package of the module tree below a root directory with
+// "go list", type-checks them together and records, for each declared function
+// and method, the call sites it contains (outgoing calls) and the call sites
+// that target it (incoming calls). The output is meant as ground truth for
+// call-hierarchy checks such as the Go LSP tests.
+package callgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// Location identifies a position in a source file. File is relative to the
+// analyzed root and uses forward slashes.
+type Location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// Call is one edge of the call graph, seen from the function that owns it.
+type Call struct {
+	// Name is the other end of the call, as "Func" or "Type.Method".
+	Name string `json:"name"`
+	// Package is the import path of the package declaring Name. Together
+	// with Name it identifies a Function.
+	Package string `json:"package"`
+	// Site is the position of the called identifier, the same position an
+	// LSP call hierarchy reports in fromRanges.
+	Site Location `json:"site"`
+}
+
+// Function describes a declared function or method and its call edges.
+type Function struct {
+	// Name is "Func" for functions and "Type.Method" for methods.
+	Name string `json:"name"`
+	// Package is the import path of the declaring package. External test
+	// packages use the "_test" suffix, as "go list" does. Files whose package
+	// clause differs from the name "go list" chose for their directory are
+	// grouped as "importpath[name]".
+	Package  string   `json:"package"`
+	Location Location `json:"location"`
+	Incoming []Call   `json:"incoming"`
+	Outgoing []Call   `json:"outgoing"`
+}
+
+// Options controls what Analyze reports.
+type Options struct {
+	// IncludeExternal adds outgoing calls to functions that are not declared
+	// under the analyzed root, such as fmt.Println.
+	IncludeExternal bool
+	// IncludeTests analyzes _test.go files as well, including external
+	// test packages.
+	IncludeTests bool
+	// Warn, if set, receives load, parse and type errors. Analysis continues
+	// past them, but calls that could not be resolved are missing from the
+	// graph.
+	Warn func(error)
+}
+
+// listedPackage is the subset of "go list -json" output Analyze uses.
+type listedPackage struct {
+	ImportPath   string
+	Name         string
+	Dir          string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	TestImports  []string
+	XTestImports []string
+	ImportMap    map[string]string
+	DepOnly      bool
+	Error        *struct{ Err string }
+}
+
+// sourcePackage is a set of files type-checked as one package.
+type sourcePackage struct {
+	path      string
+	importMap map[string]string
+	files     []*ast.File
+	analyzed  bool
+	pkg       *types.Package
+	info      *types.Info
+	state     int // 0 unchecked, 1 checking, 2 done
+}
+
+// Analyze loads the packages matching "./..." in root, which must be inside a
+// Go module, and returns all declared functions sorted by file and line.
+func Analyze(root string, opts Options) ([]*Function, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	warn := opts.Warn
+	if warn == nil {
+		warn = func(error) {}
+	}
+
+	listed, err := goList(root, "./...")
+	if err != nil {
+		return nil, err
+	}
+	if opts.IncludeTests {
+		// Imports used only by tests are outside the -deps closure of ./...
+		known := make(map[string]bool)
+		for _, lp := range listed {
+			known[lp.ImportPath] = true
+		}
+		var extra []string
+		for _, lp := range listed {
+			if lp.DepOnly {
+				continue
+			}
+			for _, imp := range append(append([]string{}, lp.TestImports...), lp.XTestImports...) {
+				if !known[imp] {
+					known[imp] = true
+					extra = append(extra, imp)
+				}
+			}
+		}
+		if len(extra) > 0 {
+			more, err := goList(root, extra...)
+			if err != nil {
+				return nil, err
+			}
+			for _, lp := range more {
+				lp.DepOnly = true
+				listed = append(listed, lp)
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	// Packages importable by path; external tests are only checked, never
+	// imported.
+	byPath := make(map[string]*sourcePackage)
+	// Packages matching ./..., whose functions are reported.
+	var all []*sourcePackage
+
+	for _, lp := range listed {
+		if byPath[lp.ImportPath] != nil {
+			continue
+		}
+		if lp.DepOnly {
+			// Dependencies are only loaded for their types; their errors
+			// do not affect the reported graph.
+			groups, _ := parseGrouped(fset, lp.Dir, append(append([]string{}, lp.GoFiles...), lp.CgoFiles...), func(error) {})
+			byPath[lp.ImportPath] = &sourcePackage{path: lp.ImportPath, importMap: lp.ImportMap, files: groups[lp.Name]}
+			continue
+		}
+		if lp.Error != nil {
+			warn(fmt.Errorf("%s: %s", lp.ImportPath, lp.Error.Err))
+		}
+		names := append(append([]string{}, lp.GoFiles...), lp.CgoFiles...)
+		if opts.IncludeTests {
+			names = append(names, lp.TestGoFiles...)
+		}
+		groups, order := parseGrouped(fset, lp.Dir, names, warn)
+		for _, name := range order {
+			sp := &sourcePackage{path: lp.ImportPath, importMap: lp.ImportMap, files: groups[name], analyzed: true}
+			if name != lp.Name {
+				sp.path = lp.ImportPath + "[" + name + "]"
+			} else {
+				byPath[lp.ImportPath] = sp
+			}
+			all = append(all, sp)
+		}
+		if opts.IncludeTests && len(lp.XTestGoFiles) > 0 {
+			groups, order := parseGrouped(fset, lp.Dir, lp.XTestGoFiles, warn)
+			for _, name := range order {
+				all = append(all, &sourcePackage{path: lp.ImportPath + "_test", importMap: lp.ImportMap, files: groups[name], analyzed: true})
+			}
+		}
+	}
+
+	var check func(sp *sourcePackage)
+	// importerFor resolves imports of sp. Every package, including the
+	// standard library and third-party dependencies, comes from the go list
+	// run in root, so the module's requirements and replace directives apply
+	// wherever the tool was started.
+	importerFor := func(sp *sourcePackage) types.Importer {
+		return importerFunc(func(path string) (*types.Package, error) {
+			if resolved, ok := sp.importMap[path]; ok {
+				path = resolved
+			}
+			dep, ok := byPath[path]
+			if !ok {
+				return nil, fmt.Errorf("package %s not found by go list", path)
+			}
+			if dep.state == 1 {
+				return nil, fmt.Errorf("import cycle through %s", path)
+			}
+			check(dep)
+			return dep.pkg, nil
+		})
+	}
+	check = func(sp *sourcePackage) {
+		if sp.state != 0 {
+			return
+		}
+		sp.state = 1
+		sp.info = &types.Info{
+			Defs:      make(map[*ast.Ident]types.Object),
+			Uses:      make(map[*ast.Ident]types.Object),
+			Instances: make(map[*ast.Ident]types.Instance),
+		}
+		conf := types.Config{
+			Importer:    importerFor(sp),
+			FakeImportC: true,
+			// Keep going on type errors so one bad file does not hide the
+			// rest of the graph.
+			Error: func(err error) {
+				if sp.analyzed {
+					warn(err)
+				}
+			},
+		}
+		sp.pkg, _ = conf.Check(sp.path, fset, sp.files, sp.info)
+		sp.state = 2
+	}
+
+	funcs := make(map[*types.Func]*Function)
+	type pendingCall struct {
+		caller *types.Func
+		callee *types.Func
+		site   Location
+	}
+	var calls []pendingCall
+
+	for _, sp := range all {
+		check(sp)
+		for _, file := range sp.files {
+			for _, decl := range file.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				obj, ok := sp.info.Defs[fd.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				funcs[obj] = &Function{
+					Name:     funcName(obj),
+					Package:  sp.path,
+					Location: location(fset, root, fd.Name.Pos()),
+					Incoming: []Call{},
+					Outgoing: []Call{},
+				}
+				if fd.Body == nil {
+					continue
+				}
+				ast.Inspect(fd.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					if callee, id := calledFunc(sp.info, call.Fun); callee != nil {
+						calls = append(calls, pendingCall{
+							caller: obj,
+							callee: callee.Origin(),
+							site:   location(fset, root, id.Pos()),
+						})
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	for _, c := range calls {
+		caller := funcs[c.caller]
+		callee, declared := funcs[c.callee]
+		if !declared && !opts.IncludeExternal {
+			continue
+		}
+		out := Call{Name: funcName(c.callee), Site: c.site}
+		if declared {
+			out.Package = callee.Package
+		} else if c.callee.Pkg() != nil {
+			out.Package = c.callee.Pkg().Path()
+		}
+		caller.Outgoing = append(caller.Outgoing, out)
+		if declared {
+			callee.Incoming = append(callee.Incoming, Call{Name: caller.Name, Package: caller.Package, Site: c.site})
+		}
+	}
+
+	result := make([]*Function, 0, len(funcs))
+	for _, f := range funcs {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lessLocation(result[i].Location, result[j].Location)
+	})
+	for _, f := range result {
+		sortCalls(f.Incoming)
+		sortCalls(f.Outgoing)
+	}
+	return result, nil
+}
+
+// goList runs "go list -e -deps -json" for patterns in root. It applies build
+// constraints and resolves import paths the same way the go command does.
+func goList(root string, patterns ...string) ([]*listedPackage, error) {
+	args := []string{"list", "-e", "-deps",
+		"-json=ImportPath,Name,Dir,GoFiles,CgoFiles,TestGoFiles,XTestGoFiles,TestImports,XTestImports,ImportMap,DepOnly,Error",
+		"--"}
+	cmd := exec.Command("go", append(args, patterns...)...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list in %s: %v: %s", root, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var pkgs []*listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode go list output: %w", err)
+		}
+		pkgs = append(pkgs, &p)
+	}
+	return pkgs, nil
+}
+
+// parseGrouped parses the named files in dir and groups them by package
+// clause, returning the package names in order of first appearance. Parse
+// errors are passed to warn; the partial syntax tree of such a file is kept
+// when the parser produced one.
+func parseGrouped(fset *token.FileSet, dir string, names []string, warn func(error)) (map[string][]*ast.File, []string) {
+	groups := make(map[string][]*ast.File)
+	var order []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			warn(err)
+		}
+		if f == nil || f.Name == nil {
+			continue
+		}
+		pkg := f.Name.Name
+		if _, ok := groups[pkg]; !ok {
+			order = append(order, pkg)
+		}
+		groups[pkg] = append(groups[pkg], f)
+	}
+	return groups, order
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// calledFunc resolves the function a call expression targets and the
+// identifier naming it. Calls through function values, conversions and
+// builtins return nil.
+func calledFunc(info *types.Info, fun ast.Expr) (*types.Func, *ast.Ident) {
+	switch f := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		if fn, ok := info.Uses[f].(*types.Func); ok {
+			return fn, f
+		}
+	case *ast.SelectorExpr:
+		if fn, ok := info.Uses[f.Sel].(*types.Func); ok {
+			return fn, f.Sel
+		}
+	case *ast.IndexExpr:
+		return calledFunc(info, f.X)
+	case *ast.IndexListExpr:
+		return calledFunc(info, f.X)
+	}
+	return nil, nil
+}
+
+// funcName returns "Func" or "Type.Method" for fn, matching how the LSP tests
+// refer to symbols.
+func funcName(fn *types.Func) string {
+	fn = fn.Origin()
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return fn.Name()
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	switch t := recv.(type) {
+	case *types.Named:
+		return t.Obj().Name() + "." + fn.Name()
+	case *types.Interface:
+		return "interface." + fn.Name()
+	}
+	return fn.Name()
+}
+
+func location(fset *token.FileSet, root string, pos token.Pos) Location {
+	p := fset.Position(pos)
+	return Location{File: relPath(root, p.Filename), Line: p.Line, Column: p.Column}
+}
+
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func lessLocation(a, b Location) bool {
+	if a.File != b.File {
+		return a.File < b.File
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+func sortCalls(calls []Call) {
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].Site != calls[j].Site {
+			return lessLocation(calls[i].Site, calls[j].Site)
+		}
+		if calls[i].Package != calls[j].Package {
+			return calls[i].Package < calls[j].Package
+		}
+		return calls[i].Name < calls[j].Name
+	})
+}
@@ -0,0 +1,213 @@
+package callgraph
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// edges maps "pkg.Name" to the sorted "pkg.Name" ends of its calls.
+type edges map[string][]string
+
+func analyze(t *testing.T, root string, opts Options) (in, out edges) {
+	t.Helper()
+	funcs, err := Analyze(root, opts)
+	if err != nil {
+		t.Fatalf("Analyze(%s): %v", root, err)
+	}
+	in, out = edges{}, edges{}
+	for _, f := range funcs {
+		key := f.Package + "." + f.Name
+		if _, dup := in[key]; dup {
+			t.Fatalf("duplicate function %s", key)
+		}
+		in[key] = callNames(f.Incoming)
+		out[key] = callNames(f.Outgoing)
+	}
+	return in, out
+}
+
+func callNames(calls []Call) []string {
+	names := []string{}
+	for _, c := range calls {
+		names = append(names, c.Package+"."+c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func noWarnings(t *testing.T) func(error) {
+	return func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	}
+}
+
+func assertEdges(t *testing.T, kind string, got, want edges) {
+	t.Helper()
+	for fn, calls := range want {
+		if calls == nil {
+			calls = []string{}
+		}
+		gotCalls, ok := got[fn]
+		if !ok {
+			t.Errorf("function %s not found", fn)
+			continue
+		}
+		if !reflect.DeepEqual(gotCalls, calls) {
+			t.Errorf("%s calls of %s = %v, want %v", kind, fn, gotCalls, calls)
+		}
+	}
+}
+
+func TestAnalyzeGoProject1(t *testing.T) {
+	in, out := analyze(t, "../../../tests/fixtures/go/project1", Options{Warn: noWarnings(t)})
+
+	const p = "probe-test-go."
+	assertEdges(t, "incoming", in, edges{
+		p + "Calculate":                  {p + "BusinessLogic.ProcessValue", p + "ProcessNumbers", p + "main"},
+		p + "Add":                        {p + "Calculate", p + "UtilityHelper", p + "main"},
+		p + "Multiply":                   {p + "Calculate", p + "UtilityHelper", p + "main"},
+		p + "Subtract":                   {p + "Calculate"},
+		p + "ProcessNumbers":             {p + "main"},
+		p + "Divide":                     nil,
+		p + "BusinessLogic.ProcessValue": nil,
+	})
+	assertEdges(t, "outgoing", out, edges{
+		p + "Calculate":                  {p + "Add", p + "Multiply", p + "Subtract"},
+		p + "main":                       {p + "Add", p + "Calculate", p + "Multiply", p + "ProcessNumbers"},
+		p + "ProcessNumbers":             {p + "Calculate"},
+		p + "BusinessLogic.ProcessValue": {p + "Calculate"},
+		p + "UtilityHelper":              {p + "Add", p + "Multiply"},
+	})
+	if len(in) != 10 {
+		t.Errorf("got %d functions, want 10", len(in))
+	}
+}
+
+func TestAnalyzeExternal(t *testing.T) {
+	_, out := analyze(t, "../../../tests/fixtures/go/project1", Options{
+		IncludeExternal: true,
+		Warn:            noWarnings(t),
+	})
+	assertEdges(t, "outgoing", out, edges{
+		"probe-test-go.main": {
+			"fmt.Printf", "fmt.Printf", "fmt.Printf", "fmt.Printf", "fmt.Println",
+			"probe-test-go.Add", "probe-test-go.Calculate", "probe-test-go.Multiply", "probe-test-go.ProcessNumbers",
+		},
+	})
+}
+
+func TestAnalyzeMultiPackage(t *testing.T) {
+	in, out := analyze(t, "testdata/multi", Options{
+		IncludeExternal: true,
+		IncludeTests:    true,
+		Warn:            noWarnings(t),
+	})
+
+	const m, s, x = "example.com/m.", "example.com/m/sub.", "example.com/m/sub_test."
+	assertEdges(t, "outgoing", out, edges{
+		m + "main":         {m + "Use", s + "Box.Get", s + "Helper", s + "Map"},
+		m + "Use":          {m + "Pair.Val"},
+		s + "Map":          {s + "Helper"},
+		s + "TestInternal": {s + "Helper"},
+		x + "TestX":        {s + "Helper"},
+	})
+	assertEdges(t, "incoming", in, edges{
+		s + "Helper":   {m + "main", s + "Map", s + "TestInternal", x + "TestX"},
+		s + "Map":      {m + "main"},
+		s + "Box.Get":  {m + "main"},
+		m + "Pair.Val": {m + "Use"},
+	})
+	for fn := range in {
+		if strings.HasSuffix(fn, ".Ignored") {
+			t.Errorf("build-constrained function %s was analyzed", fn)
+		}
+	}
+
+	in, _ = analyze(t, "testdata/multi", Options{Warn: noWarnings(t)})
+	if _, ok := in[x+"TestX"]; ok {
+		t.Errorf("TestX analyzed without IncludeTests")
+	}
+	assertEdges(t, "incoming", in, edges{
+		s + "Helper": {m + "main", s + "Map"},
+	})
+}
+
+func TestAnalyzeMixedPackages(t *testing.T) {
+	var warnings []error
+	in, out := analyze(t, "testdata/mixed", Options{
+		Warn: func(err error) { warnings = append(warnings, err) },
+	})
+
+	if len(warnings) == 0 {
+		t.Errorf("expected a warning for conflicting package clauses")
+	}
+	// go list names the package after bad.go, so the x files are the
+	// secondary group.
+	const x, y = "example.com/mixed/x[x].", "example.com/mixed/x."
+	assertEdges(t, "outgoing", out, edges{
+		x + "F": {x + "G"},
+		x + "G": nil,
+		y + "Z": nil,
+	})
+	if len(in) != 3 {
+		t.Errorf("got functions %v, want F, G and Z", in)
+	}
+}
+
+func TestAnalyzeReplacedDependency(t *testing.T) {
+	// The test runs from the package directory, outside the analyzed module,
+	// so the replace directive only applies if imports resolve from there.
+	_, out := analyze(t, "testdata/replace/app", Options{
+		IncludeExternal: true,
+		Warn:            noWarnings(t),
+	})
+	assertEdges(t, "outgoing", out, edges{
+		"example.com/app.main": {"example.com/dep.D"},
+	})
+}
+
+func TestAnalyzeSyntaxError(t *testing.T) {
+	var warnings []error
+	in, out := analyze(t, "testdata/broken", Options{
+		Warn: func(err error) { warnings = append(warnings, err) },
+	})
+
+	if len(warnings) == 0 {
+		t.Errorf("expected a warning for the syntax error in b.go")
+	}
+	assertEdges(t, "outgoing", out, edges{
+		"example.com/broken/a.A": {"example.com/broken/a.B"},
+	})
+	assertEdges(t, "incoming", in, edges{
+		"example.com/broken/a.B":    {"example.com/broken/a.A"},
+		"example.com/broken/b.Fine": nil,
+	})
+}
+
+func TestAnalyzeCallSites(t *testing.T) {
+	funcs, err := Analyze("testdata/multi", Options{Warn: noWarnings(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := map[string]Location{}
+	for _, f := range funcs {
+		if f.Name != "main" {
+			continue
+		}
+		for _, c := range f.Outgoing {
+			sites[c.Name] = c.Site
+		}
+	}
+	// Sites point at the called identifier, not the opening parenthesis.
+	want := map[string]Location{
+		"Helper":  {File: "main.go", Line: 6, Column: 6},
+		"Map":     {File: "main.go", Line: 7, Column: 6},
+		"Box.Get": {File: "main.go", Line: 9, Column: 4},
+		"Use":     {File: "main.go", Line: 10, Column: 2},
+	}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("call sites = %v, want %v", sites, want)
+	}
+}
@@ -0,0 +1,7 @@
+package a
+
+func A() {
+	B()
+}
+
+func B() {}
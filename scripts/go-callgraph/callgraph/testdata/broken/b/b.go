@@ -0,0 +1,6 @@
+package b
+
+func Fine() {}
+
+func Broken( {
+}
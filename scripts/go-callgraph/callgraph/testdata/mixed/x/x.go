@@ -0,0 +1,7 @@
+package x
+
+func F() {
+	G()
+}
+
+func G() {}
@@ -0,0 +1,14 @@
+package main
+
+type Pair[T any] struct {
+	v T
+}
+
+func (p *Pair[T]) Val() T {
+	return p.v
+}
+
+func Use() int {
+	p := &Pair[int]{}
+	return p.Val()
+}
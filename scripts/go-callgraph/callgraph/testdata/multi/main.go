@@ -0,0 +1,11 @@
+package main
+
+import "example.com/m/sub"
+
+func main() {
+	sub.Helper()
+	sub.Map(3)
+	b := &sub.Box[int]{}
+	b.Get()
+	Use()
+}
@@ -0,0 +1,7 @@
+//go:build ignore
+
+package sub
+
+func Ignored() {
+	Helper()
+}
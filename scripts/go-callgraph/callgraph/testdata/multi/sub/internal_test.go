@@ -0,0 +1,7 @@
+package sub
+
+import "testing"
+
+func TestInternal(t *testing.T) {
+	Helper()
+}
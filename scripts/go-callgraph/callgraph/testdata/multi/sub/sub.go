@@ -0,0 +1,16 @@
+package sub
+
+func Helper() {}
+
+func Map[T any](v T) T {
+	Helper()
+	return v
+}
+
+type Box[T any] struct {
+	v T
+}
+
+func (b *Box[T]) Get() T {
+	return b.v
+}
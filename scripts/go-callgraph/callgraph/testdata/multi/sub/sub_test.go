@@ -0,0 +1,11 @@
+package sub_test
+
+import (
+	"testing"
+
+	"example.com/m/sub"
+)
+
+func TestX(t *testing.T) {
+	sub.Helper()
+}
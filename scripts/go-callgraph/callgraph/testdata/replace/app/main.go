@@ -0,0 +1,7 @@
+package main
+
+import "example.com/dep"
+
+func main() {
+	dep.D()
+}
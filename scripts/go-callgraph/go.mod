@@ -0,0 +1,3 @@
+module go-callgraph
+
+go 1.22
@@ -0,0 +1,76 @@
+// Command go-callgraph prints the incoming and outgoing calls of every
+// function in a Go module tree as JSON. Load and type errors are reported on
+// stderr as warnings, since they can hide call edges.
+//
+// Usage:
+//
+//	go-callgraph [-external] [-tests] [-o out.json] DIR
+//
+// Example, generating ground truth for the Go LSP call-hierarchy fixture:
+//
+//	cd scripts/go-callgraph && go run . ../../tests/fixtures/go/project1
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go-callgraph/callgraph"
+)
+
+func main() {
+	external := flag.Bool("external", false, "include outgoing calls to functions outside DIR (e.g. fmt.Println)")
+	tests := flag.Bool("tests", false, "analyze _test.go files, including external test packages")
+	out := flag.String("o", "", "write JSON to this file instead of stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] DIR\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := callgraph.Options{
+		IncludeExternal: *external,
+		IncludeTests:    *tests,
+		Warn: func(err error) {
+			fmt.Fprintf(os.Stderr, "go-callgraph: warning: %v\n", err)
+		},
+	}
+	if err := run(flag.Arg(0), *out, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "go-callgraph: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, out string, opts callgraph.Options) error {
+	funcs, err := callgraph.Analyze(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		return encode(os.Stdout, funcs)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, funcs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func encode(w io.Writer, funcs []*callgraph.Function) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(funcs)
+}